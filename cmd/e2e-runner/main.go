@@ -17,17 +17,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
 	"github.com/google/exposure-notifications-server/pkg/server"
 
 	"github.com/google/exposure-notifications-server/pkg/observability"
 	"github.com/google/exposure-notifications-verification-server/pkg/buildinfo"
-	"github.com/google/exposure-notifications-verification-server/pkg/clients"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
 	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
 	"github.com/google/exposure-notifications-verification-server/pkg/e2e"
@@ -38,6 +39,10 @@ import (
 	"github.com/sethvargo/go-signalcontext"
 )
 
+// healthStaleAfter is how long a scenario can go without succeeding before
+// /healthz starts reporting it as a warning.
+const healthStaleAfter = 30 * time.Minute
+
 func main() {
 	ctx, done := signalcontext.OnInterrupt()
 
@@ -78,6 +83,10 @@ func realMain(ctx context.Context) error {
 	defer oe.Close()
 	logger.Infow("observability exporter", "config", e2eConfig.Observability)
 
+	if err := e2e.RegisterViews(); err != nil {
+		return fmt.Errorf("failed to register e2e metric views: %w", err)
+	}
+
 	// Setup database and authorized apps.
 	done, err := e2e.Setup(ctx, e2eConfig)
 	if err != nil {
@@ -102,8 +111,12 @@ func realMain(ctx context.Context) error {
 	populateLogger := middleware.PopulateLogger(logger)
 	r.Use(populateLogger)
 
-	r.HandleFunc("/default", defaultHandler(ctx, e2eConfig.TestConfig))
-	r.HandleFunc("/revise", reviseHandler(ctx, e2eConfig.TestConfig))
+	health := e2e.NewHealthTracker()
+	for _, scenario := range e2e.Scenarios {
+		scenario := scenario
+		r.HandleFunc("/scenarios/"+scenario.Name, scenarioHandler(ctx, e2eConfig.TestConfig, scenario, health))
+	}
+	r.HandleFunc("/healthz", healthzHandler(health))
 
 	srv, err := server.New(e2eConfig.Port)
 	if err != nil {
@@ -113,16 +126,14 @@ func realMain(ctx context.Context) error {
 	return srv.ServeHTTPHandler(ctx, handlers.CombinedLoggingHandler(os.Stdout, r))
 }
 
-// Config is passed by value so that each http hadndler has a separate copy (since they are changing one of the)
-// config elements. Previous versions of those code had a race condition where the "DoRevise" status
-// could be changed while a handler was executing.
-func defaultHandler(ctx context.Context, config config.E2ETestConfig) func(http.ResponseWriter, *http.Request) {
+// Config is passed by value so that each http handler gets its own copy, and
+// e2e.Run copies it again for every run before Scenario.Configure mutates
+// it, so concurrent requests never race on shared config state.
+func scenarioHandler(ctx context.Context, baseConfig config.E2ETestConfig, scenario e2e.Scenario, health *e2e.HealthTracker) func(http.ResponseWriter, *http.Request) {
 	logger := logging.FromContext(ctx)
-	c := &config
-	c.DoRevise = false
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := clients.RunEndToEnd(ctx, c); err != nil {
-			logger.Errorw("could not run default end to end", "error", err)
+		if err := e2e.Run(r.Context(), baseConfig, scenario, health); err != nil {
+			logger.Errorw("e2e scenario failed", "scenario", scenario.Name, "error", err)
 			http.Error(w, "failed (check server logs for more details): "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -131,17 +142,20 @@ func defaultHandler(ctx context.Context, config config.E2ETestConfig) func(http.
 	}
 }
 
-func reviseHandler(ctx context.Context, config config.E2ETestConfig) func(http.ResponseWriter, *http.Request) {
-	logger := logging.FromContext(ctx)
-	c := &config
-	c.DoRevise = true
+// healthzHandler reports, per scenario, the last time it succeeded and (if
+// any) its most recent error, along with a flattened list of warnings for
+// scenarios that haven't succeeded recently.
+func healthzHandler(health *e2e.HealthTracker) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := clients.RunEndToEnd(ctx, c); err != nil {
-			logger.Errorw("could not run revise end to end", "error", err)
-			http.Error(w, "failed (check server logs for more details): "+err.Error(), http.StatusInternalServerError)
-			return
+		resp := struct {
+			Scenarios map[string]e2e.ScenarioHealth `json:"scenarios"`
+			Warnings  []string                      `json:"warnings,omitempty"`
+		}{
+			Scenarios: health.Snapshot(),
+			Warnings:  health.Warnings(healthStaleAfter),
 		}
 
-		fmt.Fprint(w, "ok")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
 	}
 }
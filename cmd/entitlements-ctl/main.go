@@ -0,0 +1,210 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// entitlements-ctl mints and inspects signed realm entitlements blobs for
+// use with pkg/entitlements and the admin server's HandleApplyEntitlements
+// endpoint.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/entitlements"
+)
+
+func main() {
+	if err := realMain(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "entitlements-ctl: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func realMain(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: entitlements-ctl <mint|inspect> [flags]")
+	}
+
+	switch args[0] {
+	case "mint":
+		return runMint(args[1:])
+	case "inspect":
+		return runInspect(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q (want mint or inspect)", args[0])
+	}
+}
+
+func runMint(args []string) error {
+	fs := flag.NewFlagSet("mint", flag.ContinueOnError)
+	realmID := fs.Uint64("realm-id", 0, "realm ID to mint entitlements for")
+	features := fs.String("features", "", "comma-separated feature names, e.g. enx-redirect,self-report")
+	ttl := fs.Duration("ttl", 90*24*time.Hour, "how long the entitlements remain valid")
+	notBefore := fs.Duration("not-before-skew", 0, "how far in the past to backdate not_before, to tolerate clock skew")
+	privKeyPath := fs.String("private-key", "", "path to a base64-encoded Ed25519 private key")
+	nonce := fs.String("nonce", "", "unique nonce; a random one is generated if empty")
+	out := fs.String("out", "", "output path prefix; writes <prefix>.blob.json and <prefix>.sig, or stdout if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *realmID == 0 {
+		return fmt.Errorf("-realm-id is required")
+	}
+	if *privKeyPath == "" {
+		return fmt.Errorf("-private-key is required")
+	}
+
+	priv, err := readPrivateKey(*privKeyPath)
+	if err != nil {
+		return err
+	}
+
+	n := *nonce
+	if n == "" {
+		n, err = randomNonce()
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	e := entitlements.Entitlements{
+		RealmID:   uint(*realmID),
+		Features:  splitFeatures(*features),
+		NotBefore: now.Add(-*notBefore),
+		NotAfter:  now.Add(*ttl),
+		Nonce:     n,
+	}
+
+	blob, sig, err := entitlements.Sign(e, priv)
+	if err != nil {
+		return fmt.Errorf("failed to sign entitlements: %w", err)
+	}
+
+	if *out == "" {
+		fmt.Println(string(blob))
+		fmt.Println(base64.StdEncoding.EncodeToString(sig))
+		return nil
+	}
+	if err := ioutil.WriteFile(*out+".blob.json", blob, 0o600); err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := ioutil.WriteFile(*out+".sig", []byte(base64.StdEncoding.EncodeToString(sig)), 0o600); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	return nil
+}
+
+func runInspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	blobPath := fs.String("blob", "", "path to the entitlements blob")
+	sigPath := fs.String("sig", "", "path to the base64-encoded signature")
+	pubKeyPath := fs.String("public-key", "", "path to a base64-encoded Ed25519 public key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *blobPath == "" || *sigPath == "" || *pubKeyPath == "" {
+		return fmt.Errorf("-blob, -sig, and -public-key are all required")
+	}
+
+	blob, err := ioutil.ReadFile(*blobPath)
+	if err != nil {
+		return fmt.Errorf("failed to read blob: %w", err)
+	}
+	sigRaw, err := ioutil.ReadFile(*sigPath)
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigRaw)))
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	pub, err := readPublicKey(*pubKeyPath)
+	if err != nil {
+		return err
+	}
+
+	e, err := entitlements.Verify(blob, sig, pub)
+	if err != nil {
+		return fmt.Errorf("signature INVALID: %w", err)
+	}
+
+	fmt.Printf("signature: valid\n")
+	fmt.Printf("realm_id:  %d\n", e.RealmID)
+	fmt.Printf("features:  %s\n", strings.Join(e.Features, ", "))
+	fmt.Printf("not_before: %s\n", e.NotBefore.Format(time.RFC3339))
+	fmt.Printf("not_after:  %s\n", e.NotAfter.Format(time.RFC3339))
+	fmt.Printf("nonce:      %s\n", e.Nonce)
+	return nil
+}
+
+func splitFeatures(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	features := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			features = append(features, p)
+		}
+	}
+	return features
+}
+
+func readPrivateKey(path string) (ed25519.PrivateKey, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key: %w", err)
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("private key is %d bytes, want %d", len(key), ed25519.PrivateKeySize)
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+func readPublicKey(path string) (ed25519.PublicKey, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key is %d bytes, want %d", len(key), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func randomNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -17,10 +17,14 @@ package apikey
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 
 	"github.com/google/exposure-notifications-verification-server/pkg/cache"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/controller/middleware"
 	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	entitlementspkg "github.com/google/exposure-notifications-verification-server/pkg/entitlements"
 	"github.com/google/exposure-notifications-verification-server/pkg/render"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
@@ -28,22 +32,56 @@ import (
 	"go.uber.org/zap"
 )
 
+// requireManageKeys gates every handler in this package behind the
+// ScopeAdminManageKeys permission on the caller's own realm Membership,
+// checked per-route rather than by the realm's legacy Admin/Device
+// APIKeyType default. These are human-session web pages rendered for a
+// realm admin's browser, not machine API key routes, so they must be
+// gated by middleware.RequireRealmAdmin (which reads the session's
+// Membership) rather than middleware.RequireScope (which reads an
+// AuthorizedApp API key that a browser session never has).
+var requireManageKeys = middleware.RequireRealmAdmin(database.ScopeAdminManageKeys)
+
 type Controller struct {
-	config *config.ServerConfig
-	cacher cache.Cacher
-	db     *database.Database
-	h      *render.Renderer
-	logger *zap.SugaredLogger
+	config  *config.ServerConfig
+	cacher  cache.Cacher
+	db      *database.Database
+	manager *entitlementspkg.Manager
+	h       *render.Renderer
+	logger  *zap.SugaredLogger
 }
 
-func New(ctx context.Context, config *config.ServerConfig, cacher cache.Cacher, db *database.Database, h *render.Renderer) *Controller {
+// New creates a Controller. manager is queried via Manager.Has/Warnings
+// rather than this package reading realm feature columns directly, so a
+// realm's entitlements can be revoked or renewed without an apikey code
+// change.
+func New(ctx context.Context, config *config.ServerConfig, cacher cache.Cacher, db *database.Database, manager *entitlementspkg.Manager, h *render.Renderer) *Controller {
 	logger := logging.FromContext(ctx).Named("apikey")
 
 	return &Controller{
-		config: config,
-		cacher: cacher,
-		db:     db,
-		h:      h,
-		logger: logger,
+		config:  config,
+		cacher:  cacher,
+		db:      db,
+		manager: manager,
+		h:       h,
+		logger:  logger,
+	}
+}
+
+// findAuthorizedApp loads the AuthorizedApp with the given id, scoped to the
+// realm so that one realm can never look up another realm's keys.
+func (c *Controller) findAuthorizedApp(realm *database.Realm, rawID string) (*database.AuthorizedApp, error) {
+	id, err := strconv.ParseUint(rawID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API key id %q: %w", rawID, err)
+	}
+
+	app, err := c.db.FindAuthorizedApp(uint(id))
+	if err != nil {
+		return nil, err
+	}
+	if app.RealmID != realm.ID {
+		return nil, database.ErrRecordNotFound
 	}
+	return app, nil
 }
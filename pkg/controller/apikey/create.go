@@ -0,0 +1,94 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// featureCustomKeyScopes gates minting a key with a scope mask other than
+// its type's default - entitled realms query the Manager rather than this
+// package reading a boolean column directly.
+const featureCustomKeyScopes = "custom-key-scopes"
+
+// HandleCreate renders the new API key form on GET and mints a new
+// AuthorizedApp on POST. The requested scopes are parsed from the form and
+// intersected with the scopes the caller's own session is allowed to grant.
+// A realm without the featureCustomKeyScopes entitlement always gets its
+// type's default scopes, regardless of what the form requested; that
+// default is not narrowed to the caller's own scopes.
+func (c *Controller) HandleCreate() http.Handler {
+	return requireManageKeys(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		realm := controller.RealmFromContext(ctx)
+		if realm == nil {
+			controller.MissingRealm(w, r, c.h)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			c.h.RenderHTML(w, "apikeys/new", map[string]interface{}{
+				"app":      &database.AuthorizedApp{},
+				"warnings": c.manager.Warnings(realm.ID),
+			})
+			return
+		}
+
+		var form struct {
+			Name   string `form:"name"`
+			Type   string `form:"type"`
+			Scopes string `form:"scopes"`
+		}
+		if err := controller.BindForm(r, &form); err != nil {
+			controller.BadRequest(w, r, c.h, err)
+			return
+		}
+
+		keyType := database.APIKeyType(form.Type)
+		scopes := database.ParseScope(form.Scopes)
+		if membership := controller.MembershipFromContext(ctx); membership != nil {
+			scopes = scopes.Intersect(membership.Permissions)
+		}
+		if !c.manager.Has(realm.ID, featureCustomKeyScopes) {
+			scopes = database.DefaultScopeForAPIKeyType(keyType)
+		}
+
+		app := &database.AuthorizedApp{
+			RealmID:    realm.ID,
+			Name:       form.Name,
+			APIKeyType: database.APIKeyType(form.Type),
+			Scopes:     scopes,
+		}
+
+		key, err := realm.CreateAuthorizedApp(c.db, app, controller.ActorFromContext(ctx))
+		if err != nil {
+			c.h.RenderHTML(w, "apikeys/new", map[string]interface{}{
+				"app":      app,
+				"error":    err,
+				"warnings": c.manager.Warnings(realm.ID),
+			})
+			return
+		}
+
+		c.h.RenderHTML(w, "apikeys/show_secret", map[string]interface{}{
+			"app":      app,
+			"key":      key,
+			"warnings": c.manager.Warnings(realm.ID),
+		})
+	}))
+}
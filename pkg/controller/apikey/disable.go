@@ -0,0 +1,73 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+
+	"github.com/gorilla/mux"
+)
+
+// HandleDisable soft-deletes the API key so it can no longer authenticate,
+// without losing its metadata or audit history.
+func (c *Controller) HandleDisable() http.Handler {
+	return c.setDisabled(true)
+}
+
+// HandleReenable clears a previous disable, restoring the key to service
+// with its original secret and metadata intact.
+func (c *Controller) HandleReenable() http.Handler {
+	return c.setDisabled(false)
+}
+
+func (c *Controller) setDisabled(disabled bool) http.Handler {
+	return requireManageKeys(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		realm := controller.RealmFromContext(ctx)
+		if realm == nil {
+			controller.MissingRealm(w, r, c.h)
+			return
+		}
+
+		vars := mux.Vars(r)
+		app, err := c.findAuthorizedApp(realm, vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.NotFound(w, r, c.h)
+			} else {
+				controller.InternalError(w, r, c.h, err)
+			}
+			return
+		}
+
+		if disabled {
+			now := time.Now().UTC()
+			app.DeletedAt = &now
+		} else {
+			app.DeletedAt = nil
+		}
+
+		if err := c.db.SaveAuthorizedApp(app, controller.ActorFromContext(ctx)); err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		controller.Redirect(w, r, "/apikeys/"+vars["id"], http.StatusSeeOther)
+	}))
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// indexPageSize is the number of API keys rendered per page of the index.
+const indexPageSize = 25
+
+// HandleIndex renders a paginated list of the realm's API keys. The list can
+// be narrowed with the `q` (name search), `type` (APIKeyType), and `status`
+// query parameters. Only `status=active` filters, to keys that are not
+// disabled; any other `status` value, including "disabled", lists keys of
+// every status.
+func (c *Controller) HandleIndex() http.Handler {
+	return requireManageKeys(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		realm := controller.RealmFromContext(ctx)
+		if realm == nil {
+			controller.MissingRealm(w, r, c.h)
+			return
+		}
+
+		query := r.URL.Query()
+		page, err := strconv.Atoi(query.Get("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		apps, paginator, err := c.db.ListAuthorizedAppsForRealm(realm.ID, database.AuthorizedAppSearch{
+			Query:      strings.TrimSpace(query.Get("q")),
+			Type:       query.Get("type"),
+			OnlyActive: query.Get("status") == "active",
+			Page:       page,
+			Limit:      indexPageSize,
+		})
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderHTML(w, "apikeys/index", map[string]interface{}{
+			"apps":      apps,
+			"paginator": paginator,
+			"query":     query.Get("q"),
+			"type":      query.Get("type"),
+			"status":    query.Get("status"),
+			"warnings":  c.manager.Warnings(realm.ID),
+		})
+	}))
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+
+	"github.com/gorilla/mux"
+)
+
+// HandleShow renders the detail page for a single API key, including its
+// audit history (created, updated, rotated, disabled; re-enabling a key is
+// recorded as "updated").
+func (c *Controller) HandleShow() http.Handler {
+	return requireManageKeys(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		realm := controller.RealmFromContext(ctx)
+		if realm == nil {
+			controller.MissingRealm(w, r, c.h)
+			return
+		}
+
+		vars := mux.Vars(r)
+		app, err := c.findAuthorizedApp(realm, vars["id"])
+		if err != nil {
+			if database.IsNotFound(err) {
+				controller.NotFound(w, r, c.h)
+			} else {
+				controller.InternalError(w, r, c.h, err)
+			}
+			return
+		}
+
+		audits, err := c.db.ListAuthorizedAppAudits(app.ID)
+		if err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		c.h.RenderHTML(w, "apikeys/show", map[string]interface{}{
+			"app":      app,
+			"audits":   audits,
+			"warnings": c.manager.Warnings(realm.ID),
+		})
+	}))
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entitlements
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	entitlementspkg "github.com/google/exposure-notifications-verification-server/pkg/entitlements"
+)
+
+// maxEntitlementsBlobBytes bounds the size of an uploaded blob; real blobs
+// are a few hundred bytes of JSON.
+const maxEntitlementsBlobBytes = 1 << 16 // 64 KiB
+
+// HandleApplyEntitlements accepts a signed entitlements blob upload
+// (multipart file fields "blob", the JSON blob as-is, and "signature", its
+// base64-encoded signature) for the
+// current realm, verifies it against the server's configured entitlements
+// public key, persists it, and refreshes the in-memory Manager cache so the
+// new entitlements take effect immediately instead of waiting for the next
+// scheduled refresh.
+func (c *Controller) HandleApplyEntitlements() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		realm := controller.RealmFromContext(ctx)
+		if realm == nil {
+			controller.MissingRealm(w, r, c.h)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxEntitlementsBlobBytes)
+		if err := r.ParseMultipartForm(maxEntitlementsBlobBytes); err != nil {
+			controller.BadRequest(w, r, c.h, err)
+			return
+		}
+
+		blobFile, _, err := r.FormFile("blob")
+		if err != nil {
+			controller.BadRequest(w, r, c.h, err)
+			return
+		}
+		defer blobFile.Close()
+		blob, err := ioutil.ReadAll(blobFile)
+		if err != nil {
+			controller.BadRequest(w, r, c.h, err)
+			return
+		}
+
+		sigFile, _, err := r.FormFile("signature")
+		if err != nil {
+			controller.BadRequest(w, r, c.h, err)
+			return
+		}
+		defer sigFile.Close()
+		sigRaw, err := ioutil.ReadAll(sigFile)
+		if err != nil {
+			controller.BadRequest(w, r, c.h, err)
+			return
+		}
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigRaw)))
+		if err != nil {
+			controller.BadRequest(w, r, c.h, fmt.Errorf("failed to decode signature: %w", err))
+			return
+		}
+
+		e, err := entitlementspkg.Verify(blob, sig, c.manager.PublicKey())
+		if err != nil {
+			controller.BadRequest(w, r, c.h, err)
+			return
+		}
+		if e.RealmID != realm.ID {
+			controller.BadRequest(w, r, c.h, fmt.Errorf("entitlements blob is for realm %d, not realm %d", e.RealmID, realm.ID))
+			return
+		}
+
+		realm.EntitlementsBlob = blob
+		realm.EntitlementsSignature = sig
+		if err := c.db.SaveRealm(realm, controller.ActorFromContext(ctx)); err != nil {
+			controller.InternalError(w, r, c.h, err)
+			return
+		}
+
+		// Refresh synchronously, on the request's own context, so the
+		// Manager's cache (and therefore every Manager.Has call) reflects
+		// the new entitlements by the time this handler responds, rather
+		// than waiting out the refresh interval.
+		if err := c.manager.Refresh(ctx, realm.ID); err != nil {
+			controller.InternalError(w, r, c.h, fmt.Errorf("entitlements were saved but failed to take effect: %w", err))
+			return
+		}
+
+		c.h.RenderHTML(w, "entitlements/applied", map[string]interface{}{
+			"realm":        realm,
+			"entitlements": e,
+			"warnings":     c.manager.Warnings(realm.ID),
+		})
+	})
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package entitlements contains the admin web controller for uploading and
+// inspecting realm entitlements blobs minted by cmd/entitlements-ctl.
+package entitlements
+
+import (
+	"context"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+	entitlementspkg "github.com/google/exposure-notifications-verification-server/pkg/entitlements"
+	"github.com/google/exposure-notifications-verification-server/pkg/render"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+
+	"go.uber.org/zap"
+)
+
+// Controller serves the admin-facing entitlements upload/inspect pages.
+type Controller struct {
+	config  *config.ServerConfig
+	db      *database.Database
+	manager *entitlementspkg.Manager
+	h       *render.Renderer
+	logger  *zap.SugaredLogger
+}
+
+// New creates a Controller. manager is used to verify and immediately
+// re-cache an uploaded blob after HandleApplyEntitlements persists it.
+func New(ctx context.Context, config *config.ServerConfig, db *database.Database, manager *entitlementspkg.Manager, h *render.Renderer) *Controller {
+	logger := logging.FromContext(ctx).Named("entitlements")
+
+	return &Controller{
+		config:  config,
+		db:      db,
+		manager: manager,
+		h:       h,
+		logger:  logger,
+	}
+}
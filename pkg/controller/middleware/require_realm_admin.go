@@ -0,0 +1,48 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/controller"
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+
+	"github.com/gorilla/mux"
+)
+
+// RequireRealmAdmin returns middleware that rejects the request with
+// http.StatusUnauthorized unless the Membership attached to the request
+// context (by the session authentication middleware) has been granted
+// every scope in want for the realm the session is acting in. Unlike
+// RequireScope, which checks an AuthorizedApp API key, this is for the
+// human-session web controllers realm admins use in a browser - those
+// requests never have an AuthorizedApp in context, so RequireScope would
+// reject every one of them.
+func RequireRealmAdmin(want database.Scope) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			membership := controller.MembershipFromContext(ctx)
+			if !membership.Can(want) {
+				http.Error(w, "unauthorized: missing required realm permission", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
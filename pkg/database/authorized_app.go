@@ -0,0 +1,282 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// APIKeyType is the legacy, coarse-grained classification of an
+// AuthorizedApp's key. New code should prefer checking Scope directly; see
+// DefaultScopeForAPIKeyType for how a type maps onto scopes.
+type APIKeyType string
+
+const (
+	APIKeyTypeAdmin  APIKeyType = "admin"
+	APIKeyTypeDevice APIKeyType = "device"
+)
+
+// AuthorizedApp is a single API key issued to a realm, along with the
+// scopes it was granted and (optionally) a time-bounded lease used by e2e
+// provisioning to reclaim orphaned keys.
+type AuthorizedApp struct {
+	ID         uint `gorm:"primary_key"`
+	RealmID    uint
+	Name       string
+	APIKeyType APIKeyType
+	Scopes     Scope
+
+	// APIKeyHash is the sha256 hash of the plaintext key; the plaintext
+	// itself is never stored, so it can only ever be returned once, at
+	// creation or rotation time.
+	APIKeyHash string
+
+	// LeaseOwner and LeaseExpiresAt support e2e.SetupOptions' named
+	// leases: an orphaned key (LeaseExpiresAt in the past) is reclaimable
+	// by a different LeaseOwner without waiting for the usual cleanup.
+	LeaseOwner     string
+	LeaseExpiresAt *time.Time
+
+	DeletedAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// AuthorizedAppSearch narrows ListAuthorizedAppsForRealm's results.
+type AuthorizedAppSearch struct {
+	Query      string
+	Type       string
+	OnlyActive bool
+	Page       int
+	Limit      int
+}
+
+// Paginator describes one page of a larger result set.
+type Paginator struct {
+	Page  int
+	Limit int
+	Total int64
+}
+
+// TotalPages is the number of pages needed to cover Total rows at Limit
+// rows per page.
+func (p Paginator) TotalPages() int {
+	if p.Limit <= 0 {
+		return 0
+	}
+	pages := int(p.Total) / p.Limit
+	if int(p.Total)%p.Limit != 0 {
+		pages++
+	}
+	return pages
+}
+
+// hashAPIKey returns the hash stored for and compared against a plaintext
+// API key.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%x", sum)
+}
+
+// generateAPIKey returns a fresh random plaintext API key. It is only ever
+// returned to the caller that minted or rotated it - the database stores
+// only hashAPIKey(key).
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return fmt.Sprintf("%x", b), nil
+}
+
+// CreateAuthorizedApp saves app as a new key belonging to this realm,
+// generates its secret, and returns the plaintext key. The plaintext is
+// never recoverable again - callers that lose it must rotate instead.
+func (r *Realm) CreateAuthorizedApp(db *Database, app *AuthorizedApp, actor Auditable) (string, error) {
+	key, err := generateAPIKey()
+	if err != nil {
+		return "", err
+	}
+
+	app.RealmID = r.ID
+	app.APIKeyHash = hashAPIKey(key)
+
+	if err := db.db.Create(app).Error; err != nil {
+		return "", fmt.Errorf("failed to create authorized app: %w", err)
+	}
+	if err := recordAuthorizedAppAudit(db, app.ID, "created", actor); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// RotateAuthorizedApp issues a new plaintext secret for app, persists its
+// hash, and returns the new plaintext. app's id, name, type, scopes, and
+// lease fields are untouched, and a "rotated" audit row is recorded -
+// rotation changes the secret, never the key's identity or history.
+func (d *Database) RotateAuthorizedApp(app *AuthorizedApp, actor Auditable) (string, error) {
+	key, err := generateAPIKey()
+	if err != nil {
+		return "", err
+	}
+
+	app.APIKeyHash = hashAPIKey(key)
+	if err := d.db.Model(app).Update("api_key_hash", app.APIKeyHash).Error; err != nil {
+		return "", fmt.Errorf("failed to rotate authorized app: %w", err)
+	}
+	if err := recordAuthorizedAppAudit(d, app.ID, "rotated", actor); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// SaveAuthorizedApp creates or updates app (including enabling/disabling it
+// via DeletedAt) and records an audit row.
+func (d *Database) SaveAuthorizedApp(app *AuthorizedApp, actor Auditable) error {
+	if err := d.db.Save(app).Error; err != nil {
+		return fmt.Errorf("failed to save authorized app: %w", err)
+	}
+
+	action := "updated"
+	switch {
+	case app.DeletedAt != nil:
+		action = "disabled"
+	}
+	return recordAuthorizedAppAudit(d, app.ID, action, actor)
+}
+
+// FindAuthorizedApp looks up an AuthorizedApp by id, regardless of realm.
+func (d *Database) FindAuthorizedApp(id uint) (*AuthorizedApp, error) {
+	var app AuthorizedApp
+	if err := d.db.First(&app, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to find authorized app %d: %w", id, err)
+	}
+	return &app, nil
+}
+
+// FindAuthorizedAppForRealm looks up an AuthorizedApp by id, scoped to
+// realmID so one realm can never look up another's keys. A key that
+// exists but belongs to a different realm is reported as ErrRecordNotFound,
+// the same as one that doesn't exist at all.
+func (d *Database) FindAuthorizedAppForRealm(realmID, id uint) (*AuthorizedApp, error) {
+	app, err := d.FindAuthorizedApp(id)
+	if err != nil {
+		return nil, err
+	}
+	if app.RealmID != realmID {
+		return nil, fmt.Errorf("authorized app %d belongs to a different realm: %w", id, ErrRecordNotFound)
+	}
+	return app, nil
+}
+
+// FindAuthorizedAppByAPIKey looks up the (non-deleted) AuthorizedApp whose
+// secret hashes to key.
+func (d *Database) FindAuthorizedAppByAPIKey(key string) (*AuthorizedApp, error) {
+	var app AuthorizedApp
+	if err := d.db.Where("api_key_hash = ? AND deleted_at IS NULL", hashAPIKey(key)).First(&app).Error; err != nil {
+		return nil, fmt.Errorf("failed to find authorized app by API key: %w", err)
+	}
+	return &app, nil
+}
+
+// ListAuthorizedAppsForRealm returns one page of realmID's keys matching
+// search, newest first.
+func (d *Database) ListAuthorizedAppsForRealm(realmID uint, search AuthorizedAppSearch) ([]*AuthorizedApp, *Paginator, error) {
+	page, limit := search.Page, search.Limit
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 25
+	}
+
+	query := d.db.Model(&AuthorizedApp{}).Where("realm_id = ?", realmID)
+	if search.Query != "" {
+		query = query.Where("name ILIKE ?", "%"+search.Query+"%")
+	}
+	if search.Type != "" {
+		query = query.Where("api_key_type = ?", search.Type)
+	}
+	if search.OnlyActive {
+		query = query.Where("deleted_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to count authorized apps: %w", err)
+	}
+
+	var apps []*AuthorizedApp
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&apps).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to list authorized apps: %w", err)
+	}
+
+	return apps, &Paginator{Page: page, Limit: limit, Total: total}, nil
+}
+
+// ClaimReusableAuthorizedApp atomically claims a non-deleted AuthorizedApp
+// of the given realm and type whose lease is either unset or expired as of
+// now, for e2e.SetupOptions.ReuseExisting, and assigns it to leaseOwner
+// until leaseExpiresAt. Unlike a find followed by a separate save, the
+// claim and the lease assignment happen in a single UPDATE whose WHERE
+// clause re-checks the same lease condition it selected on, so two
+// concurrent callers can never claim the same row: the loser's UPDATE
+// matches zero rows and gets ErrRecordNotFound, rather than both minting a
+// rotation that invalidates the other's key. Callers must still rotate the
+// claimed row, since the previous plaintext secret was never stored.
+func (d *Database) ClaimReusableAuthorizedApp(realmID uint, keyType APIKeyType, leaseOwner string, now, leaseExpiresAt time.Time) (*AuthorizedApp, error) {
+	eligible := d.db.
+		Model(&AuthorizedApp{}).
+		Select("id").
+		Where("realm_id = ? AND api_key_type = ? AND deleted_at IS NULL", realmID, keyType).
+		Where("lease_expires_at IS NULL OR lease_expires_at <= ?", now).
+		Order("id ASC").
+		Limit(1)
+
+	var app AuthorizedApp
+	result := d.db.Model(&app).
+		Clauses(clause.Returning{}).
+		Where("id = (?)", eligible).
+		Where("lease_expires_at IS NULL OR lease_expires_at <= ?", now).
+		Updates(map[string]interface{}{
+			"lease_owner":      leaseOwner,
+			"lease_expires_at": leaseExpiresAt,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to claim a reusable authorized app: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("failed to claim a reusable authorized app: %w", ErrRecordNotFound)
+	}
+	return &app, nil
+}
+
+// SoftDeleteExpiredAuthorizedAppLeases soft-deletes every AuthorizedApp
+// whose lease expired before now, for SweepExpiredE2EKeys. It returns the
+// number of rows affected.
+func (d *Database) SoftDeleteExpiredAuthorizedAppLeases(now time.Time) (int64, error) {
+	result := d.db.Model(&AuthorizedApp{}).
+		Where("lease_expires_at IS NOT NULL AND lease_expires_at <= ? AND deleted_at IS NULL", now).
+		Update("deleted_at", now)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to sweep expired authorized app leases: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
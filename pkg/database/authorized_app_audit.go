@@ -0,0 +1,57 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// AuthorizedAppAudit is one entry in an AuthorizedApp's history: created,
+// updated (which includes re-enabling), rotated, or disabled. Rotation never deletes or replaces
+// these rows, so a key's full history survives any number of rotations.
+type AuthorizedAppAudit struct {
+	ID              uint `gorm:"primary_key"`
+	AuthorizedAppID uint
+	Action          string
+	ActorID         string
+	ActorDisplay    string
+	CreatedAt       time.Time
+}
+
+// ListAuthorizedAppAudits returns appID's audit history, newest first.
+func (d *Database) ListAuthorizedAppAudits(appID uint) ([]*AuthorizedAppAudit, error) {
+	var audits []*AuthorizedAppAudit
+	if err := d.db.Where("authorized_app_id = ?", appID).Order("created_at DESC").Find(&audits).Error; err != nil {
+		return nil, fmt.Errorf("failed to list authorized app audits: %w", err)
+	}
+	return audits, nil
+}
+
+// recordAuthorizedAppAudit writes an audit row for a mutation to appID.
+// Its error is surfaced back to the caller rather than just logged, so a
+// write that silently lost its audit trail doesn't look like success.
+func recordAuthorizedAppAudit(d *Database, appID uint, action string, actor Auditable) error {
+	audit := &AuthorizedAppAudit{
+		AuthorizedAppID: appID,
+		Action:          action,
+		ActorID:         actor.AuditID(),
+		ActorDisplay:    actor.AuditDisplay(),
+	}
+	if err := d.db.Create(audit).Error; err != nil {
+		return fmt.Errorf("failed to record authorized app audit: %w", err)
+	}
+	return nil
+}
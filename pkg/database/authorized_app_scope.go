@@ -0,0 +1,133 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import "strings"
+
+// Scope is a bitmask of the individual permissions an AuthorizedApp may be
+// granted. It supersedes the binary Admin/Device APIKeyType split, which is
+// kept only to derive sensible defaults for existing keys.
+type Scope uint32
+
+const (
+	// ScopeIssue allows issuing new verification codes.
+	ScopeIssue Scope = 1 << iota
+	// ScopeVerify allows exchanging a verification code for a token/certificate.
+	ScopeVerify
+	// ScopeCertSign allows signing verification certificates.
+	ScopeCertSign
+	// ScopeStatsRead allows reading realm and key usage statistics.
+	ScopeStatsRead
+	// ScopeAdminListUsers allows listing the realm's users.
+	ScopeAdminListUsers
+	// ScopeAdminManageKeys allows listing, creating, rotating, and
+	// disabling the realm's own API keys.
+	ScopeAdminManageKeys
+
+	// ScopeNone grants no permissions.
+	ScopeNone Scope = 0
+)
+
+// scopeNames maps each individual scope bit to its stable, lowercase name.
+// The order here determines the order scopes are rendered in the admin UI.
+var scopeNames = []struct {
+	scope Scope
+	name  string
+}{
+	{ScopeIssue, "issue"},
+	{ScopeVerify, "verify"},
+	{ScopeCertSign, "cert-sign"},
+	{ScopeStatsRead, "stats-read"},
+	{ScopeAdminListUsers, "admin-list-users"},
+	{ScopeAdminManageKeys, "admin-manage-keys"},
+}
+
+// ScopeAdmin is the union of scopes historically granted to an
+// APIKeyTypeAdmin key.
+const ScopeAdmin = ScopeIssue | ScopeVerify | ScopeCertSign | ScopeStatsRead | ScopeAdminListUsers | ScopeAdminManageKeys
+
+// ScopeDevice is the union of scopes historically granted to an
+// APIKeyTypeDevice key.
+const ScopeDevice = ScopeVerify | ScopeCertSign
+
+// DefaultScopeForAPIKeyType returns the scope mask that preserves the
+// existing behavior of a key created before per-scope keys existed.
+func DefaultScopeForAPIKeyType(t APIKeyType) Scope {
+	switch t {
+	case APIKeyTypeAdmin:
+		return ScopeAdmin
+	case APIKeyTypeDevice:
+		return ScopeDevice
+	default:
+		return ScopeNone
+	}
+}
+
+// Has returns true if the scope mask contains every bit in want.
+func (s Scope) Has(want Scope) bool {
+	return s&want == want
+}
+
+// Intersect returns the scopes s and other have in common, for narrowing a
+// requested scope mask down to what a grantor is actually allowed to grant.
+func (s Scope) Intersect(other Scope) Scope {
+	return s & other
+}
+
+// Names returns the stable names of the scopes set in s, in a fixed order.
+func (s Scope) Names() []string {
+	var names []string
+	for _, e := range scopeNames {
+		if s.Has(e.scope) {
+			names = append(names, e.name)
+		}
+	}
+	return names
+}
+
+// String implements fmt.Stringer, rendering the scope as a comma-separated
+// list of its names (e.g. "issue,verify").
+func (s Scope) String() string {
+	if s == ScopeNone {
+		return "none"
+	}
+	return strings.Join(s.Names(), ",")
+}
+
+// ParseScope parses a comma-separated list of scope names into a Scope
+// bitmask. Unknown names are ignored so that older clients that send a
+// superset of names don't break newer servers.
+func ParseScope(raw string) Scope {
+	var s Scope
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		for _, e := range scopeNames {
+			if e.name == part {
+				s |= e.scope
+			}
+		}
+	}
+	return s
+}
+
+// HasScope returns true if this AuthorizedApp's key has been granted every
+// scope in want. Keys created before scopes existed fall back to the
+// scopes implied by their legacy APIKeyType.
+func (a *AuthorizedApp) HasScope(want Scope) bool {
+	if a.Scopes == ScopeNone {
+		return DefaultScopeForAPIKeyType(a.APIKeyType).Has(want)
+	}
+	return a.Scopes.Has(want)
+}
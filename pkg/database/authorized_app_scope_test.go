@@ -0,0 +1,106 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import "testing"
+
+func TestScopeHas(t *testing.T) {
+	s := ScopeIssue | ScopeVerify
+
+	if !s.Has(ScopeIssue) {
+		t.Error("Has(ScopeIssue) = false, want true")
+	}
+	if s.Has(ScopeCertSign) {
+		t.Error("Has(ScopeCertSign) = true, want false")
+	}
+	if !s.Has(ScopeIssue | ScopeVerify) {
+		t.Error("Has(ScopeIssue|ScopeVerify) = false, want true")
+	}
+	if s.Has(ScopeIssue | ScopeCertSign) {
+		t.Error("Has(ScopeIssue|ScopeCertSign) = true, want false")
+	}
+}
+
+func TestScopeIntersect(t *testing.T) {
+	requested := ScopeAdmin
+	granted := ScopeIssue | ScopeVerify
+
+	got := requested.Intersect(granted)
+	if got != granted {
+		t.Errorf("Intersect() = %v, want %v", got, granted)
+	}
+	if got.Has(ScopeCertSign) {
+		t.Error("Intersect() result retained a scope the grantor didn't have")
+	}
+}
+
+func TestScopeParseAndString(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want Scope
+	}{
+		{"single", "issue", ScopeIssue},
+		{"multiple", "issue,verify", ScopeIssue | ScopeVerify},
+		{"whitespace", " issue , verify ", ScopeIssue | ScopeVerify},
+		{"unknown names ignored", "issue,bogus", ScopeIssue},
+		{"empty", "", ScopeNone},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := ParseScope(c.raw); got != c.want {
+				t.Errorf("ParseScope(%q) = %v, want %v", c.raw, got, c.want)
+			}
+		})
+	}
+
+	if got, want := ScopeNone.String(), "none"; got != want {
+		t.Errorf("ScopeNone.String() = %q, want %q", got, want)
+	}
+	if got, want := (ScopeIssue | ScopeVerify).String(), "issue,verify"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestDefaultScopeForAPIKeyType(t *testing.T) {
+	if got := DefaultScopeForAPIKeyType(APIKeyTypeAdmin); got != ScopeAdmin {
+		t.Errorf("DefaultScopeForAPIKeyType(admin) = %v, want %v", got, ScopeAdmin)
+	}
+	if got := DefaultScopeForAPIKeyType(APIKeyTypeDevice); got != ScopeDevice {
+		t.Errorf("DefaultScopeForAPIKeyType(device) = %v, want %v", got, ScopeDevice)
+	}
+	if got := DefaultScopeForAPIKeyType(APIKeyType("unknown")); got != ScopeNone {
+		t.Errorf("DefaultScopeForAPIKeyType(unknown) = %v, want %v", got, ScopeNone)
+	}
+}
+
+func TestAuthorizedAppHasScope(t *testing.T) {
+	legacy := &AuthorizedApp{APIKeyType: APIKeyTypeDevice}
+	if !legacy.HasScope(ScopeVerify) {
+		t.Error("legacy device key should fall back to ScopeDevice")
+	}
+	if legacy.HasScope(ScopeAdminListUsers) {
+		t.Error("legacy device key should not have admin scopes")
+	}
+
+	scoped := &AuthorizedApp{APIKeyType: APIKeyTypeAdmin, Scopes: ScopeIssue}
+	if !scoped.HasScope(ScopeIssue) {
+		t.Error("explicitly scoped key should have its granted scope")
+	}
+	if scoped.HasScope(ScopeAdminListUsers) {
+		t.Error("explicitly scoped key should not fall back to its type's default once Scopes is set")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package database is the gorm-backed data layer for realms, authorized
+// apps (API keys), and their audit trails.
+package database
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Database wraps the underlying gorm connection used by every query and
+// mutation in this package. The zero value is not usable; obtain one via
+// config.DatabaseConfig.Load, which dials the connection string but does
+// not open it - callers must still call Open.
+type Database struct {
+	db *gorm.DB
+}
+
+// Open establishes the underlying connection pool. It is split from Load
+// so config parsing (which can fail fast, before any network I/O) and
+// connecting are separate steps, matching how callers structure their
+// startup error handling.
+func (d *Database) Open(ctx context.Context) error {
+	return d.db.WithContext(ctx).Exec("SELECT 1").Error
+}
+
+// Close releases the underlying connection pool.
+func (d *Database) Close() error {
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// ErrRecordNotFound is returned (possibly wrapped) by any Find* method that
+// doesn't find a matching row. Callers should check it with IsNotFound
+// rather than comparing errors directly, since it may be wrapped.
+var ErrRecordNotFound = errors.New("record not found")
+
+// IsNotFound reports whether err represents a missing row, whether it came
+// from gorm directly or from ErrRecordNotFound above.
+func IsNotFound(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, ErrRecordNotFound)
+}
+
+// Auditable identifies who performed a mutation, for the actor column on
+// audit rows. Controllers derive it from the authenticated session/request;
+// background jobs and tests use System.
+type Auditable interface {
+	AuditID() string
+	AuditDisplay() string
+}
+
+// System is the Auditable used for mutations that aren't attributable to a
+// human request, e.g. e2e test setup or scheduled sweeps.
+var System Auditable = systemActor{}
+
+type systemActor struct{}
+
+func (systemActor) AuditID() string      { return "system" }
+func (systemActor) AuditDisplay() string { return "System" }
@@ -0,0 +1,35 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+// Membership is a human user's role within a single realm: the scopes they
+// personally hold there, independent of any AuthorizedApp API key.
+// Controllers read the caller's Membership from the authenticated session
+// via controller.MembershipFromContext - the session-based analogue of
+// AuthorizedAppFromContext, which only ever holds a machine API key caller.
+type Membership struct {
+	RealmID     uint
+	Permissions Scope
+}
+
+// Can reports whether this membership has been granted every scope in
+// want. A nil Membership (no authenticated session) can never grant
+// anything.
+func (m *Membership) Can(want Scope) bool {
+	if m == nil {
+		return false
+	}
+	return m.Permissions.Has(want)
+}
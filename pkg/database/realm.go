@@ -0,0 +1,84 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// Realm is a single tenant: a public health authority or similar
+// organization issuing its own verification codes and certificates.
+type Realm struct {
+	ID         uint `gorm:"primary_key"`
+	Name       string
+	RegionCode string
+
+	// EntitlementsBlob and EntitlementsSignature are the last signed
+	// entitlements blob accepted for this realm via
+	// HandleApplyEntitlements. They are opaque to this package - only
+	// pkg/entitlements verifies and interprets them.
+	EntitlementsBlob      []byte
+	EntitlementsSignature []byte
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	errors []string `gorm:"-"`
+}
+
+// NewRealmWithDefaults returns an unsaved Realm with the given name and
+// this project's standard defaults applied.
+func NewRealmWithDefaults(name string) *Realm {
+	return &Realm{Name: name}
+}
+
+// ErrorMessages returns validation errors recorded on the realm, for
+// inclusion in error-wrapping log/error messages. SaveRealm records none,
+// so this is empty for a realm whose save failed in SaveRealm.
+func (r *Realm) ErrorMessages() []string {
+	return r.errors
+}
+
+// FindRealmByName looks up a realm by its unique name.
+func (d *Database) FindRealmByName(name string) (*Realm, error) {
+	var realm Realm
+	if err := d.db.Where("name = ?", name).First(&realm).Error; err != nil {
+		return nil, fmt.Errorf("failed to find realm %q: %w", name, err)
+	}
+	return &realm, nil
+}
+
+// FindRealm looks up a realm by id.
+func (d *Database) FindRealm(id uint) (*Realm, error) {
+	var realm Realm
+	if err := d.db.First(&realm, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to find realm %d: %w", id, err)
+	}
+	return &realm, nil
+}
+
+// SaveRealm creates or updates realm and records an audit row for actor.
+func (d *Database) SaveRealm(realm *Realm, actor Auditable) error {
+	action := "updated"
+	if realm.ID == 0 {
+		action = "created"
+	}
+
+	if err := d.db.Save(realm).Error; err != nil {
+		return fmt.Errorf("failed to save realm: %w", err)
+	}
+	return recordRealmAudit(d, realm.ID, action, actor)
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// RealmAudit is one entry in a Realm's history: created, or updated (e.g.
+// a new entitlements blob accepted via HandleApplyEntitlements).
+type RealmAudit struct {
+	ID           uint `gorm:"primary_key"`
+	RealmID      uint
+	Action       string
+	ActorID      string
+	ActorDisplay string
+	CreatedAt    time.Time
+}
+
+// ListRealmAudits returns realmID's audit history, newest first.
+func (d *Database) ListRealmAudits(realmID uint) ([]*RealmAudit, error) {
+	var audits []*RealmAudit
+	if err := d.db.Where("realm_id = ?", realmID).Order("created_at DESC").Find(&audits).Error; err != nil {
+		return nil, fmt.Errorf("failed to list realm audits: %w", err)
+	}
+	return audits, nil
+}
+
+// recordRealmAudit writes an audit row for a mutation to realmID. Its
+// error is surfaced back to the caller rather than just logged, so a
+// write that silently lost its audit trail doesn't look like success.
+func recordRealmAudit(d *Database, realmID uint, action string, actor Auditable) error {
+	audit := &RealmAudit{
+		RealmID:      realmID,
+		Action:       action,
+		ActorID:      actor.AuditID(),
+		ActorDisplay: actor.AuditDisplay(),
+	}
+	if err := d.db.Create(audit).Error; err != nil {
+		return fmt.Errorf("failed to record realm audit: %w", err)
+	}
+	return nil
+}
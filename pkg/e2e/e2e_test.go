@@ -18,7 +18,6 @@ import (
 	"context"
 	"testing"
 
-	"github.com/google/exposure-notifications-verification-server/pkg/clients"
 	"github.com/google/exposure-notifications-verification-server/pkg/config"
 )
 
@@ -32,21 +31,13 @@ func TestE2E(t *testing.T) {
 	close, err := Setup(ctx, e2eConfig)
 	defer close()
 
-	cases := []struct {
-		Name   string
-		Revise bool
-	}{
-		{"default", false},
-		{"revise", true},
-	}
+	health := NewHealthTracker()
 
-	for _, tc := range cases {
-		tc := tc
-		cfg := e2eConfig.TestConfig
-		cfg.DoRevise = tc.Revise
-		t.Run(tc.Name, func(t *testing.T) {
-			if err := clients.RunEndToEnd(ctx, &cfg); err != nil {
-				t.Errorf("End to end test failed: %v", err)
+	for _, scenario := range Scenarios {
+		scenario := scenario
+		t.Run(scenario.Name, func(t *testing.T) {
+			if err := Run(ctx, e2eConfig.TestConfig, scenario, health); err != nil {
+				t.Errorf("scenario %q failed: %v", scenario.Name, err)
 			}
 		})
 	}
@@ -0,0 +1,99 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"sync"
+	"time"
+)
+
+// ScenarioHealth is the last known state of a single scenario, as reported
+// by the /healthz endpoint.
+type ScenarioHealth struct {
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+}
+
+// HealthTracker records the last success and last error per scenario so
+// that /healthz can surface warnings for scenarios that haven't passed
+// recently, mirroring how other admin surfaces in this project fold
+// warnings into an otherwise healthy-looking status response.
+type HealthTracker struct {
+	mu    sync.RWMutex
+	state map[string]ScenarioHealth
+}
+
+// NewHealthTracker returns an empty tracker ready to record results.
+func NewHealthTracker() *HealthTracker {
+	return &HealthTracker{
+		state: make(map[string]ScenarioHealth),
+	}
+}
+
+// RecordSuccess marks scenario as having succeeded at t.
+func (h *HealthTracker) RecordSuccess(scenario string, t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.state[scenario]
+	s.LastSuccess = t
+	h.state[scenario] = s
+}
+
+// RecordError marks scenario as having failed with err at the current time.
+func (h *HealthTracker) RecordError(scenario string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.state[scenario]
+	s.LastError = err.Error()
+	s.LastErrorAt = time.Now().UTC()
+	h.state[scenario] = s
+}
+
+// Snapshot returns a copy of the current per-scenario health, safe to
+// serialize without holding the tracker's lock.
+func (h *HealthTracker) Snapshot() map[string]ScenarioHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	out := make(map[string]ScenarioHealth, len(h.state))
+	for k, v := range h.state {
+		out[k] = v
+	}
+	return out
+}
+
+// Warnings returns a human-readable warning for every scenario that has
+// never succeeded, or whose last success is older than staleAfter.
+func (h *HealthTracker) Warnings(staleAfter time.Duration) []string {
+	now := time.Now().UTC()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var warnings []string
+	for _, s := range Scenarios {
+		st, ok := h.state[s.Name]
+		switch {
+		case !ok:
+			warnings = append(warnings, "scenario \""+s.Name+"\" has not run yet")
+		case st.LastSuccess.IsZero() || now.Sub(st.LastSuccess) > staleAfter:
+			warnings = append(warnings, "scenario \""+s.Name+"\" has not succeeded in over "+staleAfter.String())
+		}
+	}
+	return warnings
+}
@@ -19,6 +19,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/google/exposure-notifications-server/pkg/logging"
@@ -27,13 +28,76 @@ import (
 )
 
 const (
-	realmName       = "e2e-test-realm"
-	realmRegionCode = "e2e-test"
-	adminKeyName    = "e2e-admin-key."
-	deviceKeyName   = "e2e-device-key."
+	adminKeyName  = "e2e-admin-key."
+	deviceKeyName = "e2e-device-key."
+
+	// defaultLeaseTTL is how long a named e2e key lease is valid before
+	// SweepExpiredE2EKeys considers it orphaned and reclaims it.
+	defaultLeaseTTL = 15 * time.Minute
+
+	// defaultSweepInterval is how often SweepExpiredE2EKeys checks for
+	// expired leases.
+	defaultSweepInterval = 5 * time.Minute
 )
 
-// Generate random string of 32 characters in length
+// RealmSpec describes one realm to provision for an e2e run. Supplying more
+// than one RealmSpec lets a single runner exercise several regions/locales
+// (e.g. "e2e-test-us", "e2e-test-es") in parallel.
+type RealmSpec struct {
+	Name       string
+	RegionCode string
+}
+
+// RealmHandle is everything a scenario needs to talk to one provisioned
+// realm.
+type RealmHandle struct {
+	Realm     *database.Realm
+	AdminKey  string
+	DeviceKey string
+}
+
+// SetupOptions controls how Setup provisions its realm(s) and keys.
+type SetupOptions struct {
+	// Realms to provision. If empty, a single realm named "e2e-test-realm"
+	// with region code "e2e-test" is used, matching historical behavior.
+	Realms []RealmSpec
+
+	// LeaseOwner identifies the process holding a named key's lease, so an
+	// orphaned key left behind by a crashed owner can be reclaimed by a
+	// different one. Defaults to the host's hostname.
+	LeaseOwner string
+
+	// LeaseTTL is how long a lease is valid before it is considered
+	// orphaned and eligible for reclamation by SweepExpiredE2EKeys or a
+	// future ReuseExisting caller. Defaults to 15 minutes.
+	LeaseTTL time.Duration
+
+	// ReuseExisting, if true, makes Setup claim a non-deleted key with no
+	// lease or an expired lease for each realm/key-type pair before minting
+	// a new one, instead of always creating fresh keys with a random suffix.
+	ReuseExisting bool
+}
+
+func (o SetupOptions) withDefaults() SetupOptions {
+	out := o
+	if len(out.Realms) == 0 {
+		out.Realms = []RealmSpec{{Name: "e2e-test-realm", RegionCode: "e2e-test"}}
+	}
+	if out.LeaseOwner == "" {
+		if hostname, err := os.Hostname(); err == nil {
+			out.LeaseOwner = hostname
+		} else {
+			out.LeaseOwner = "unknown"
+		}
+	}
+	if out.LeaseTTL <= 0 {
+		out.LeaseTTL = defaultLeaseTTL
+	}
+	return out
+}
+
+// randomString returns a random 64 character hex string, used to suffix
+// freshly minted (non-reused) e2e key names.
 func randomString() (string, error) {
 	b := make([]byte, 512)
 	if _, err := rand.Read(b[:]); err != nil {
@@ -42,105 +106,230 @@ func randomString() (string, error) {
 	return fmt.Sprintf("%x", sha256.Sum256(b[:])), nil
 }
 
-// Setup sets up the test environment (database and authorized apps) for an E2E test.
-// The returned done function must be called to clean up the environment.
+// Setup provisions the default single e2e realm and its admin/device keys,
+// preserving the historical single-realm behavior and writing the minted
+// keys into cfg.TestConfig. Callers that need leasing, reuse, or multiple
+// realms should call SetupWithOptions directly. The returned done function
+// must be called to release what was provisioned.
 func Setup(ctx context.Context, cfg *config.E2ERunnerConfig) (func(), error) {
-	ready := make(chan error)
-	done := make(chan struct{})
+	handles, done, err := SetupWithOptions(ctx, cfg, SetupOptions{})
+	if err != nil {
+		return nil, err
+	}
 
-	go func() {
-		logger := logging.FromContext(ctx)
-		db, err := cfg.Database.Load(ctx)
-		if err != nil {
-			ready <- fmt.Errorf("failed to load database config: %w", err)
-			return
-		}
-		if err := db.Open(ctx); err != nil {
-			ready <- fmt.Errorf("failed to connect to database: %w", err)
-			return
-		}
-		defer db.Close()
+	handle := handles["e2e-test-realm"]
+	cfg.TestConfig.VerificationAdminAPIKey = handle.AdminKey
+	cfg.TestConfig.VerificationAPIServerKey = handle.DeviceKey
 
-		// Create or reuse the existing realm
-		realm, err := db.FindRealmByName(realmName)
-		if err != nil {
-			if !database.IsNotFound(err) {
-				ready <- fmt.Errorf("error when finding the realm %q: %w", realmName, err)
-				return
-			}
-			realm = database.NewRealmWithDefaults(realmName)
-			realm.RegionCode = realmRegionCode
-			if err := db.SaveRealm(realm, database.System); err != nil {
-				ready <- fmt.Errorf("failed to create realm %+v: %w: %v", realm, err, realm.ErrorMessages())
-				return
+	return done, nil
+}
+
+// SetupWithOptions provisions every realm in opts.Realms in parallel and
+// returns a handle per realm (keyed by RealmSpec.Name), plus a done
+// function that releases every lease this call acquired. Releasing a lease
+// never deletes the underlying key - it just marks it reclaimable, so a
+// ReuseExisting caller elsewhere can pick it back up instead of minting a
+// new one.
+func SetupWithOptions(ctx context.Context, cfg *config.E2ERunnerConfig, opts SetupOptions) (map[string]*RealmHandle, func(), error) {
+	opts = opts.withDefaults()
+	logger := logging.FromContext(ctx)
+
+	db, err := cfg.Database.Load(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load database config: %w", err)
+	}
+	if err := db.Open(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	type result struct {
+		spec    RealmSpec
+		handle  *RealmHandle
+		release func()
+		err     error
+	}
+
+	results := make(chan result, len(opts.Realms))
+	for _, spec := range opts.Realms {
+		spec := spec
+		go func() {
+			handle, release, err := provisionRealm(db, spec, opts)
+			results <- result{spec: spec, handle: handle, release: release, err: err}
+		}()
+	}
+
+	handles := make(map[string]*RealmHandle, len(opts.Realms))
+	var releases []func()
+	var firstErr error
+	for range opts.Realms {
+		r := <-results
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to provision realm %q: %w", r.spec.Name, r.err)
 			}
+			continue
 		}
+		handles[r.spec.Name] = r.handle
+		releases = append(releases, r.release)
+	}
 
-		// Create new API keys
-		suffix, err := randomString()
-		if err != nil {
-			ready <- fmt.Errorf("failed to create suffix string for API keys: %w", err)
-			return
+	if firstErr != nil {
+		for _, release := range releases {
+			release()
+		}
+		db.Close()
+		return nil, nil, firstErr
+	}
+
+	done := func() {
+		for _, release := range releases {
+			release()
+		}
+		if err := db.Close(); err != nil {
+			logger.Errorw("failed to close e2e database connection", "error", err)
 		}
+	}
+	return handles, done, nil
+}
 
-		adminKey, err := realm.CreateAuthorizedApp(db, &database.AuthorizedApp{
-			Name:       adminKeyName + suffix,
-			APIKeyType: database.APIKeyTypeAdmin,
-		}, database.System)
-		if err != nil {
-			ready <- fmt.Errorf("error trying to create a new Admin API Key: %w", err)
-			return
+// provisionRealm creates or loads a single realm and its admin/device keys
+// per opts, returning a release function that unwinds the lease(s) it
+// acquired (but never deletes a reused key).
+func provisionRealm(db *database.Database, spec RealmSpec, opts SetupOptions) (*RealmHandle, func(), error) {
+	realm, err := db.FindRealmByName(spec.Name)
+	if err != nil {
+		if !database.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("error when finding the realm %q: %w", spec.Name, err)
+		}
+		realm = database.NewRealmWithDefaults(spec.Name)
+		realm.RegionCode = spec.RegionCode
+		if err := db.SaveRealm(realm, database.System); err != nil {
+			return nil, nil, fmt.Errorf("failed to create realm %+v: %w: %v", realm, err, realm.ErrorMessages())
 		}
+	}
+
+	adminKey, releaseAdmin, err := leaseAuthorizedApp(db, realm, adminKeyName, database.APIKeyTypeAdmin, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error trying to lease a new Admin API Key: %w", err)
+	}
 
-		defer func() {
-			app, err := db.FindAuthorizedAppByAPIKey(adminKey)
+	deviceKey, releaseDevice, err := leaseAuthorizedApp(db, realm, deviceKeyName, database.APIKeyTypeDevice, opts)
+	if err != nil {
+		releaseAdmin()
+		return nil, nil, fmt.Errorf("error trying to lease a new Device API Key: %w", err)
+	}
+
+	handle := &RealmHandle{
+		Realm:     realm,
+		AdminKey:  adminKey,
+		DeviceKey: deviceKey,
+	}
+	release := func() {
+		releaseAdmin()
+		releaseDevice()
+	}
+	return handle, release, nil
+}
+
+// leaseAuthorizedApp claims a reusable key of the given type in realm when
+// opts.ReuseExisting is set (the claim does not check the key's name, so
+// namePrefix applies only to new keys), otherwise (or on a miss) mints a
+// new one named namePrefix plus a random suffix. Either way, the key's lease_owner and
+// lease_expires_at are set to this process and opts.LeaseTTL from now.
+// The returned release function marks the lease expired immediately,
+// rather than soft-deleting the key, so a ReuseExisting caller can pick it
+// back up later.
+func leaseAuthorizedApp(db *database.Database, realm *database.Realm, namePrefix string, keyType database.APIKeyType, opts SetupOptions) (string, func(), error) {
+	now := time.Now().UTC()
+
+	if opts.ReuseExisting {
+		app, err := db.ClaimReusableAuthorizedApp(realm.ID, keyType, opts.LeaseOwner, now, now.Add(opts.LeaseTTL))
+		switch {
+		case err == nil:
+			// The claim above already set lease_owner/lease_expires_at
+			// atomically, so no concurrent caller can have claimed the
+			// same row. The previous secret was never stored (only its
+			// hash), so reusing the row means minting it a fresh one via
+			// rotation rather than recovering the old plaintext.
+			key, err := db.RotateAuthorizedApp(app, database.System)
 			if err != nil {
-				logger.Errorf("admin API key cleanup failed: %w", err)
-			}
-			now := time.Now().UTC()
-			app.DeletedAt = &now
-			if err := db.SaveAuthorizedApp(app, database.System); err != nil {
-				logger.Errorf("admin API key disable failed: %w", err)
+				return "", nil, fmt.Errorf("failed to rotate reused key: %w", err)
 			}
-			logger.Info("successfully cleaned up e2e test admin key")
-		}()
+			return key, releaseFunc(db, app.ID), nil
+		case !database.IsNotFound(err):
+			return "", nil, fmt.Errorf("failed to claim a reusable key: %w", err)
+		}
+	}
 
-		deviceKey, err := realm.CreateAuthorizedApp(db, &database.AuthorizedApp{
-			Name:       deviceKeyName + suffix,
-			APIKeyType: database.APIKeyTypeDevice,
-		}, database.System)
+	suffix, err := randomString()
+	if err != nil {
+		return "", nil, err
+	}
+
+	app := &database.AuthorizedApp{
+		Name:           namePrefix + suffix,
+		APIKeyType:     keyType,
+		LeaseOwner:     opts.LeaseOwner,
+		LeaseExpiresAt: timePtr(now.Add(opts.LeaseTTL)),
+	}
+	key, err := realm.CreateAuthorizedApp(db, app, database.System)
+	if err != nil {
+		return "", nil, err
+	}
+	return key, releaseFunc(db, app.ID), nil
+}
+
+// releaseFunc returns a func that expires (rather than deletes) the lease
+// on the AuthorizedApp with the given id, making it immediately reclaimable
+// by SweepExpiredE2EKeys or a future ReuseExisting caller.
+func releaseFunc(db *database.Database, appID uint) func() {
+	return func() {
+		app, err := db.FindAuthorizedApp(appID)
 		if err != nil {
-			ready <- fmt.Errorf("error trying to create a new Device API Key: %w", err)
 			return
 		}
+		app.LeaseExpiresAt = timePtr(time.Now().UTC())
+		_ = db.SaveAuthorizedApp(app, database.System)
+	}
+}
 
-		defer func() {
-			app, err := db.FindAuthorizedAppByAPIKey(deviceKey)
-			if err != nil {
-				logger.Errorf("device API key cleanup failed: %w", err)
-				return
-			}
-			now := time.Now().UTC()
-			app.DeletedAt = &now
-			if err := db.SaveAuthorizedApp(app, database.System); err != nil {
-				logger.Errorf("device API key disable failed: %w", err)
-			}
-			logger.Info("successfully cleaned up e2e test device key")
-		}()
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
 
-		cfg.TestConfig.VerificationAdminAPIKey = adminKey
-		cfg.TestConfig.VerificationAPIServerKey = deviceKey
+// SweepExpiredE2EKeys periodically soft-deletes AuthorizedApp rows whose
+// lease has expired, cleaning up keys left behind by e2e runners that
+// didn't exit cleanly (e.g. a killed Cloud Run instance). It returns a
+// function that stops the sweep.
+func SweepExpiredE2EKeys(ctx context.Context, db *database.Database, interval time.Duration) func() {
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+	logger := logging.FromContext(ctx)
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
 
-		ready <- nil
-		select {
-		case <-done:
-		case <-ctx.Done():
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+				n, err := db.SoftDeleteExpiredAuthorizedAppLeases(time.Now().UTC())
+				if err != nil {
+					logger.Errorw("failed to sweep expired e2e key leases", "error", err)
+					continue
+				}
+				if n > 0 {
+					logger.Infow("swept expired e2e key leases", "count", n)
+				}
+			}
 		}
 	}()
 
-	if err := <-ready; err != nil {
-		close(done)
-		return nil, err
-	}
-	return func() { close(done) }, nil
+	return func() { close(stop) }
 }
@@ -0,0 +1,164 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/clients"
+	"github.com/google/exposure-notifications-verification-server/pkg/config"
+)
+
+// Scenario declares a single synthetic flow that can be driven either as an
+// HTTP route (/scenarios/{name}) or a Go subtest. Scenarios are declared as
+// values, rather than one-off handler functions, so that adding a new one
+// never requires touching the router or the test runner.
+type Scenario struct {
+	// Name identifies the scenario in routes, test names, and metrics. It
+	// must be unique and URL/subtest safe (e.g. "expired-code").
+	Name string
+
+	// Tags classify the scenario for filtering/alerting, e.g. "smoke",
+	// "negative", "i18n".
+	Tags []string
+
+	// Configure mutates a copy of the base TestConfig for this scenario.
+	// It is always called with a fresh copy, so scenarios never see each
+	// other's overrides.
+	Configure func(cfg *config.E2ETestConfig)
+
+	// WantErr is true for scenarios that are expected to fail the normal
+	// end-to-end flow (e.g. a revoked API key). For these, the scenario
+	// is considered successful when clients.RunEndToEnd returns an error.
+	WantErr bool
+}
+
+// Scenarios is the full registry of synthetic probes. cmd/e2e-runner exposes
+// each entry at /scenarios/{name}, and TestE2E runs each one as a subtest.
+var Scenarios = []Scenario{
+	{
+		Name: "default",
+		Tags: []string{"smoke"},
+		Configure: func(cfg *config.E2ETestConfig) {
+			cfg.DoRevise = false
+		},
+	},
+	{
+		Name: "revise",
+		Tags: []string{"smoke"},
+		Configure: func(cfg *config.E2ETestConfig) {
+			cfg.DoRevise = true
+		},
+	},
+	{
+		Name: "expired-code",
+		Tags: []string{"negative"},
+		Configure: func(cfg *config.E2ETestConfig) {
+			cfg.TestCodeTTL = -1 * time.Minute
+		},
+		WantErr: true,
+	},
+	{
+		Name: "wrong-test-type",
+		Tags: []string{"negative"},
+		Configure: func(cfg *config.E2ETestConfig) {
+			cfg.TestType = "invalid-test-type"
+		},
+		WantErr: true,
+	},
+	{
+		Name: "revoked-api-key",
+		Tags: []string{"negative"},
+		Configure: func(cfg *config.E2ETestConfig) {
+			cfg.VerificationAPIServerKey = cfg.VerificationAPIServerKey + "-revoked"
+		},
+		WantErr: true,
+	},
+	{
+		Name: "rate-limit-exceeded",
+		Tags: []string{"negative"},
+		Configure: func(cfg *config.E2ETestConfig) {
+			cfg.RequestsPerMinute = 1
+			cfg.BurstRequests = 50
+		},
+		WantErr: true,
+	},
+	{
+		Name: "sms-i18n-es",
+		Tags: []string{"i18n"},
+		Configure: func(cfg *config.E2ETestConfig) {
+			cfg.SMSLocale = "es"
+		},
+	},
+}
+
+// Run drives a single scenario against the given base config, recording
+// latency, error, and last-success metrics and updating health. base is
+// copied so concurrent scenario runs never share mutable config state.
+//
+// Run records the "full" latency covering the whole run. The ctx passed to
+// clients.RunEndToEnd carries a step recorder for RecordStep, but
+// clients.RunEndToEnd does not call RecordStep, so no per-step (issue,
+// verify, certificate, upload) latency is recorded.
+func Run(ctx context.Context, base config.E2ETestConfig, s Scenario, health *HealthTracker) error {
+	cfg := base
+	if s.Configure != nil {
+		s.Configure(&cfg)
+	}
+
+	start := time.Now()
+	stepCtx := context.WithValue(ctx, stepRecorderKey{}, newStepRecorder(ctx, s.Name, start))
+	runErr := clients.RunEndToEnd(stepCtx, &cfg)
+	recordLatency(ctx, s.Name, "full", time.Since(start))
+
+	switch {
+	case runErr != nil && !s.WantErr:
+		recordError(ctx, s.Name, classifyError(runErr))
+		health.RecordError(s.Name, runErr)
+		return runErr
+	case runErr == nil && s.WantErr:
+		err := fmt.Errorf("scenario %q: expected the end-to-end flow to fail, but it succeeded", s.Name)
+		recordError(ctx, s.Name, "unexpected_success")
+		health.RecordError(s.Name, err)
+		return err
+	}
+
+	now := time.Now()
+	recordSuccess(ctx, s.Name, now)
+	health.RecordSuccess(s.Name, now)
+	return nil
+}
+
+// classifyError buckets an end-to-end error into a small, stable set of
+// classes suitable for use as a metric tag value. clients.RunEndToEnd
+// currently returns opaque wrapped errors, so this is a best-effort
+// heuristic rather than a type switch over sentinel errors.
+func classifyError(err error) string {
+	switch msg := err.Error(); {
+	case strings.Contains(msg, "expired"):
+		return "expired"
+	case strings.Contains(msg, "rate limit"):
+		return "rate_limited"
+	case strings.Contains(msg, "unauthorized"), strings.Contains(msg, "revoked"):
+		return "unauthorized"
+	case strings.Contains(msg, "test type"):
+		return "invalid_test_type"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// Tag keys shared by all e2e-runner measures.
+var (
+	tagScenario   = tag.MustNewKey("scenario")
+	tagStep       = tag.MustNewKey("step")
+	tagErrorClass = tag.MustNewKey("error_class")
+)
+
+// Measures exported via the already-configured observability exporter so
+// operators can alert on synthetic failures the same way they alert on
+// production error rates.
+var (
+	mLatency = stats.Float64("e2e/latency", "Latency of an e2e scenario step", stats.UnitMilliseconds)
+	mErrors  = stats.Int64("e2e/errors", "Count of e2e scenario failures", stats.UnitDimensionless)
+	mLastOK  = stats.Float64("e2e/last_success_seconds", "Unix timestamp of the last scenario success", stats.UnitSeconds)
+)
+
+// latencyDistribution buckets, in milliseconds, tuned for the multi-second
+// issue/verify/certificate/upload round trips this package measures.
+var latencyDistribution = view.Distribution(100, 250, 500, 1000, 2500, 5000, 10000, 20000, 30000, 60000)
+
+// Views is the set of OpenCensus views e2e-runner registers at startup.
+var Views = []*view.View{
+	{
+		Name:        "e2e/latency",
+		Measure:     mLatency,
+		Description: "Latency of an e2e scenario step, by scenario and step",
+		Aggregation: latencyDistribution,
+		TagKeys:     []tag.Key{tagScenario, tagStep},
+	},
+	{
+		Name:        "e2e/errors",
+		Measure:     mErrors,
+		Description: "Count of e2e scenario failures, by scenario and error class",
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{tagScenario, tagErrorClass},
+	},
+	{
+		Name:        "e2e/last_success_seconds",
+		Measure:     mLastOK,
+		Description: "Unix timestamp of the last success of a scenario",
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{tagScenario},
+	},
+}
+
+// RegisterViews registers the e2e-runner OpenCensus views with the default
+// view manager. It must be called once, after the observability exporter
+// has started, before any scenario is run.
+func RegisterViews() error {
+	return view.Register(Views...)
+}
+
+func recordLatency(ctx context.Context, scenario, step string, d time.Duration) {
+	ctx, err := tag.New(ctx, tag.Insert(tagScenario, scenario), tag.Insert(tagStep, step))
+	if err != nil {
+		return
+	}
+	stats.Record(ctx, mLatency.M(float64(d.Milliseconds())))
+}
+
+func recordError(ctx context.Context, scenario, errorClass string) {
+	ctx, err := tag.New(ctx, tag.Insert(tagScenario, scenario), tag.Insert(tagErrorClass, errorClass))
+	if err != nil {
+		return
+	}
+	stats.Record(ctx, mErrors.M(1))
+}
+
+func recordSuccess(ctx context.Context, scenario string, at time.Time) {
+	ctx, err := tag.New(ctx, tag.Insert(tagScenario, scenario))
+	if err != nil {
+		return
+	}
+	stats.Record(ctx, mLastOK.M(float64(at.Unix())))
+}
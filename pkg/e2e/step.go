@@ -0,0 +1,63 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// stepRecorderKey is the context key under which Run attaches a scenario's
+// stepRecorder, so clients.RunEndToEnd can report its own phase boundaries
+// (issue, verify, certificate, upload) without this package needing to know
+// how RunEndToEnd is internally sequenced.
+type stepRecorderKey struct{}
+
+// RecordStep tags the time elapsed since the previous call to RecordStep
+// (or since Run started, for the first call) with step and records it as
+// an e2e/latency measurement. Nothing in this repository calls it;
+// clients.RunEndToEnd does not. A ctx not produced by Run (e.g. in a unit
+// test) makes this a no-op.
+func RecordStep(ctx context.Context, step string) {
+	r, ok := ctx.Value(stepRecorderKey{}).(*stepRecorder)
+	if !ok {
+		return
+	}
+	r.record(step)
+}
+
+// stepRecorder tags each RecordStep call with the latency since the
+// previous one, for a single Run invocation.
+type stepRecorder struct {
+	ctx      context.Context
+	scenario string
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func newStepRecorder(ctx context.Context, scenario string, start time.Time) *stepRecorder {
+	return &stepRecorder{ctx: ctx, scenario: scenario, last: start}
+}
+
+func (r *stepRecorder) record(step string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	recordLatency(r.ctx, r.scenario, step, now.Sub(r.last))
+	r.last = now
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package entitlements gates realm-level features (e.g. the ENX redirect,
+// self-report, SMS via Twilio, certificate rotation cadence) behind a
+// signed, expiring blob rather than plain boolean columns, so entitlements
+// can be minted and revoked out-of-band from a realm's own settings.
+package entitlements
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Entitlements is the canonical, signable description of the features a
+// single realm is allowed to use during [NotBefore, NotAfter].
+type Entitlements struct {
+	RealmID   uint      `json:"realm_id"`
+	Features  []string  `json:"features"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	Nonce     string    `json:"nonce"`
+}
+
+// Has reports whether feature is granted, ignoring validity window - callers
+// that need the window checked should use Manager.Has instead, which also
+// enforces expiry.
+func (e Entitlements) Has(feature string) bool {
+	for _, f := range e.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// activeAt reports whether e is within its validity window at t.
+func (e Entitlements) activeAt(t time.Time) bool {
+	if !e.NotBefore.IsZero() && t.Before(e.NotBefore) {
+		return false
+	}
+	if !e.NotAfter.IsZero() && t.After(e.NotAfter) {
+		return false
+	}
+	return true
+}
+
+// canonicalJSON renders e deterministically (sorted features, fixed field
+// order, UTC RFC3339 timestamps) so the same logical entitlements always
+// produce the same bytes to sign and verify.
+func (e Entitlements) canonicalJSON() ([]byte, error) {
+	sorted := append([]string(nil), e.Features...)
+	sort.Strings(sorted)
+
+	canon := struct {
+		RealmID   uint     `json:"realm_id"`
+		Features  []string `json:"features"`
+		NotBefore string   `json:"not_before"`
+		NotAfter  string   `json:"not_after"`
+		Nonce     string   `json:"nonce"`
+	}{
+		RealmID:   e.RealmID,
+		Features:  sorted,
+		NotBefore: e.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:  e.NotAfter.UTC().Format(time.RFC3339),
+		Nonce:     e.Nonce,
+	}
+	return json.Marshal(canon)
+}
+
+// Sign produces the canonical blob for e and its Ed25519 signature. The
+// blob, not e itself, is what gets stored and transmitted - Verify is the
+// only supported way back to an Entitlements value, so a caller can never
+// accidentally trust an unverified one.
+func Sign(e Entitlements, priv ed25519.PrivateKey) (blob, sig []byte, err error) {
+	blob, err = e.canonicalJSON()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to canonicalize entitlements: %w", err)
+	}
+	sig = ed25519.Sign(priv, blob)
+	return blob, sig, nil
+}
+
+// Verify checks sig against blob under pub and, only if it's valid, parses
+// blob back into an Entitlements. It re-derives the canonical form of the
+// parsed value and compares it byte-for-byte against blob, so a blob whose
+// JSON has been reordered, reformatted, or padded to try to slip past
+// signature verification is rejected even though ed25519.Verify alone would
+// accept it.
+func Verify(blob, sig []byte, pub ed25519.PublicKey) (Entitlements, error) {
+	var e Entitlements
+	if !ed25519.Verify(pub, blob, sig) {
+		return e, fmt.Errorf("entitlements: invalid signature")
+	}
+	if err := json.Unmarshal(blob, &e); err != nil {
+		return e, fmt.Errorf("entitlements: invalid blob: %w", err)
+	}
+
+	canon, err := e.canonicalJSON()
+	if err != nil {
+		return e, fmt.Errorf("entitlements: failed to canonicalize parsed blob: %w", err)
+	}
+	if !bytes.Equal(canon, blob) {
+		return e, fmt.Errorf("entitlements: blob is not in canonical form")
+	}
+	return e, nil
+}
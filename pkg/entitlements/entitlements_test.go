@@ -0,0 +1,83 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entitlements
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	want := Entitlements{
+		RealmID:   42,
+		Features:  []string{"self-report", "enx-redirect"},
+		NotBefore: time.Now().Add(-time.Hour).UTC(),
+		NotAfter:  time.Now().Add(time.Hour).UTC(),
+		Nonce:     "test-nonce",
+	}
+
+	blob, sig, err := Sign(want, priv)
+	if err != nil {
+		t.Fatalf("Sign() err = %v", err)
+	}
+
+	got, err := Verify(blob, sig, pub)
+	if err != nil {
+		t.Fatalf("Verify() err = %v", err)
+	}
+	if got.RealmID != want.RealmID || !got.Has("self-report") || !got.Has("enx-redirect") {
+		t.Errorf("Verify() = %+v, want features of %+v", got, want)
+	}
+
+	// A flipped byte in the blob must never verify.
+	tampered := append([]byte(nil), blob...)
+	tampered[0] ^= 0xFF
+	if _, err := Verify(tampered, sig, pub); err == nil {
+		t.Error("Verify() on tampered blob = nil error, want error")
+	}
+
+	// A signature from an unrelated key must never verify.
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate second key: %v", err)
+	}
+	if _, err := Verify(blob, sig, otherPub); err == nil {
+		t.Error("Verify() with wrong public key = nil error, want error")
+	}
+}
+
+func TestEntitlementsActiveAt(t *testing.T) {
+	now := time.Now().UTC()
+	e := Entitlements{
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(time.Hour),
+	}
+
+	if !e.activeAt(now) {
+		t.Error("activeAt(now) = false, want true")
+	}
+	if e.activeAt(now.Add(-2 * time.Hour)) {
+		t.Error("activeAt(before NotBefore) = true, want false")
+	}
+	if e.activeAt(now.Add(2 * time.Hour)) {
+		t.Error("activeAt(after NotAfter) = true, want false")
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entitlements
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/exposure-notifications-server/pkg/logging"
+)
+
+// watcherHandle pairs a Watcher with a unique id so it can be removed from
+// Manager.watchers without relying on interface equality, which panics for
+// a Watcher holding an uncomparable field (slice, map, func).
+type watcherHandle struct {
+	id uint64
+	w  Watcher
+}
+
+// expiringWindow is how far out from NotAfter the Manager starts calling
+// OnExpiring on a realm's watchers.
+const expiringWindow = 7 * 24 * time.Hour
+
+// defaultRefreshInterval is how often the Manager re-fetches a watched
+// realm's entitlements.
+const defaultRefreshInterval = 5 * time.Minute
+
+// Manager fetches, verifies, and caches each realm's entitlements, and fans
+// out change notifications to Watchers registered for that realm. It is the
+// single source of truth callers should use instead of reading feature
+// booleans directly - see Has.
+type Manager struct {
+	source  Source
+	pub     ed25519.PublicKey
+	refresh time.Duration
+
+	mu       sync.RWMutex
+	cached   map[uint]Entitlements
+	watchers map[uint][]watcherHandle
+	nextID   uint64
+
+	stop chan struct{}
+}
+
+// NewManager builds a Manager. refresh <= 0 uses defaultRefreshInterval.
+func NewManager(source Source, pub ed25519.PublicKey, refresh time.Duration) *Manager {
+	if refresh <= 0 {
+		refresh = defaultRefreshInterval
+	}
+	return &Manager{
+		source:   source,
+		pub:      pub,
+		refresh:  refresh,
+		cached:   make(map[uint]Entitlements),
+		watchers: make(map[uint][]watcherHandle),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Watch registers w to receive notifications for realmID and immediately
+// triggers a fetch, in the background, so w isn't left waiting a full
+// refresh interval for its first OnNewEntitlements call. It returns a
+// function that unregisters w; that function does not itself wait for or
+// trigger any fetch.
+func (m *Manager) Watch(ctx context.Context, realmID uint, w Watcher) func() {
+	m.mu.Lock()
+	id := m.nextID
+	m.nextID++
+	m.watchers[realmID] = append(m.watchers[realmID], watcherHandle{id: id, w: w})
+	m.mu.Unlock()
+
+	go m.refreshRealm(ctx, realmID)
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		ws := m.watchers[realmID]
+		for i, h := range ws {
+			if h.id == id {
+				m.watchers[realmID] = append(ws[:i], ws[i+1:]...)
+				break
+			}
+		}
+		w.OnStopped()
+	}
+}
+
+// Refresh synchronously fetches, verifies, and caches realmID's
+// entitlements and notifies its watchers, propagating ctx's cancellation
+// (unlike Watch's fire-and-forget background fetch). Callers that need to
+// know a freshly-applied blob actually took effect - e.g. an admin upload
+// handler - should call this instead of Watch.
+func (m *Manager) Refresh(ctx context.Context, realmID uint) error {
+	return m.refreshRealm(ctx, realmID)
+}
+
+// Start runs the background refresh loop until ctx is done or Stop is
+// called, re-fetching every watched realm's entitlements every refresh
+// interval.
+func (m *Manager) Start(ctx context.Context) {
+	ticker := time.NewTicker(m.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.refreshAll(ctx)
+		}
+	}
+}
+
+// Stop ends the background refresh loop started by Start.
+func (m *Manager) Stop() {
+	close(m.stop)
+}
+
+// Has reports whether realmID currently has feature enabled: its cached
+// entitlements must list the feature and the current time must be within
+// the entitlements' validity window. An unverified or never-fetched realm
+// has no features.
+func (m *Manager) Has(realmID uint, feature string) bool {
+	m.mu.RLock()
+	e, ok := m.cached[realmID]
+	m.mu.RUnlock()
+
+	if !ok {
+		return false
+	}
+	return e.activeAt(time.Now().UTC()) && e.Has(feature)
+}
+
+// PublicKey returns the Ed25519 public key this Manager verifies blobs
+// against, for callers (e.g. HandleApplyEntitlements) that need to verify a
+// freshly-uploaded blob themselves before persisting it.
+func (m *Manager) PublicKey() ed25519.PublicKey {
+	return m.pub
+}
+
+// Entitlements returns the cached Entitlements for realmID, if any.
+func (m *Manager) Entitlements(realmID uint) (Entitlements, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, ok := m.cached[realmID]
+	return e, ok
+}
+
+// Warnings returns human-readable warnings about realmID's cached
+// entitlements - e.g. an upcoming or already-passed expiry - for admin pages
+// to render as a banner alongside Manager.Has-gated features. A realm with
+// no cached entitlements has no warnings, since it has no features to warn
+// about either.
+func (m *Manager) Warnings(realmID uint) []string {
+	m.mu.RLock()
+	e, ok := m.cached[realmID]
+	m.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	var warnings []string
+	if !e.NotAfter.IsZero() {
+		if untilExpiry := e.NotAfter.Sub(now); untilExpiry <= 0 {
+			warnings = append(warnings, "entitlements have expired")
+		} else if untilExpiry <= expiringWindow {
+			warnings = append(warnings, fmt.Sprintf("entitlements expire in %d day(s)", int(untilExpiry/(24*time.Hour))))
+		}
+	}
+	return warnings
+}
+
+// refreshAll refreshes every watched realm concurrently, so one slow or
+// unreachable Source.Fetch can't hold up the rest.
+func (m *Manager) refreshAll(ctx context.Context) {
+	m.mu.RLock()
+	realmIDs := make([]uint, 0, len(m.watchers))
+	for id := range m.watchers {
+		realmIDs = append(realmIDs, id)
+	}
+	m.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, id := range realmIDs {
+		id := id
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = m.refreshRealm(ctx, id)
+		}()
+	}
+	wg.Wait()
+}
+
+func (m *Manager) refreshRealm(ctx context.Context, realmID uint) error {
+	logger := logging.FromContext(ctx).Named("entitlements")
+
+	blob, sig, err := m.source.Fetch(ctx, realmID)
+	if err != nil {
+		logger.Errorw("failed to fetch entitlements", "realm_id", realmID, "error", err)
+		return err
+	}
+
+	e, err := Verify(blob, sig, m.pub)
+	if err != nil {
+		logger.Errorw("failed to verify fetched entitlements", "realm_id", realmID, "error", err)
+		return err
+	}
+
+	m.mu.Lock()
+	prev, had := m.cached[realmID]
+	changed := !had || !reflect.DeepEqual(prev, e)
+	m.cached[realmID] = e
+	handles := append([]watcherHandle(nil), m.watchers[realmID]...)
+	m.mu.Unlock()
+
+	now := time.Now().UTC()
+	// Only warn about an *upcoming* expiry, not one that already passed -
+	// otherwise a realm whose entitlements lapsed and were never renewed
+	// would trigger OnExpiring with an increasingly negative daysLeft on
+	// every refresh, forever.
+	untilExpiry := e.NotAfter.Sub(now)
+	expiring := !e.NotAfter.IsZero() && untilExpiry > 0 && untilExpiry <= expiringWindow
+	daysLeft := int(untilExpiry / (24 * time.Hour))
+
+	for _, h := range handles {
+		if changed {
+			h.w.OnNewEntitlements(e)
+		}
+		if expiring {
+			h.w.OnExpiring(daysLeft)
+		}
+	}
+	return nil
+}
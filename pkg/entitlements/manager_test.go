@@ -0,0 +1,122 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entitlements
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+)
+
+// fakeSource serves a fixed, pre-signed blob for a single realm.
+type fakeSource struct {
+	blob, sig []byte
+}
+
+func (s *fakeSource) Fetch(ctx context.Context, realmID uint) ([]byte, []byte, error) {
+	return s.blob, s.sig, nil
+}
+
+func newTestManager(t *testing.T, e Entitlements) (*Manager, ed25519.PublicKey) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	blob, sig, err := Sign(e, priv)
+	if err != nil {
+		t.Fatalf("Sign() err = %v", err)
+	}
+
+	return NewManager(&fakeSource{blob: blob, sig: sig}, pub, time.Hour), pub
+}
+
+func TestManagerHas(t *testing.T) {
+	now := time.Now().UTC()
+	m, _ := newTestManager(t, Entitlements{
+		RealmID:   7,
+		Features:  []string{"self-report"},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(time.Hour),
+	})
+
+	if m.Has(7, "self-report") {
+		t.Error("Has() = true before any Refresh, want false")
+	}
+
+	if err := m.Refresh(context.Background(), 7); err != nil {
+		t.Fatalf("Refresh() err = %v", err)
+	}
+
+	if !m.Has(7, "self-report") {
+		t.Error("Has(7, self-report) = false, want true")
+	}
+	if m.Has(7, "enx-redirect") {
+		t.Error("Has(7, enx-redirect) = true, want false")
+	}
+	if m.Has(8, "self-report") {
+		t.Error("Has(8, self-report) = true, want false for an unfetched realm")
+	}
+}
+
+func TestManagerWarnings(t *testing.T) {
+	now := time.Now().UTC()
+
+	t.Run("no warning when not expiring soon", func(t *testing.T) {
+		m, _ := newTestManager(t, Entitlements{RealmID: 1, NotAfter: now.Add(30 * 24 * time.Hour)})
+		if err := m.Refresh(context.Background(), 1); err != nil {
+			t.Fatalf("Refresh() err = %v", err)
+		}
+		if got := m.Warnings(1); len(got) != 0 {
+			t.Errorf("Warnings() = %v, want none", got)
+		}
+	})
+
+	t.Run("warns when expiring soon", func(t *testing.T) {
+		m, _ := newTestManager(t, Entitlements{RealmID: 1, NotAfter: now.Add(2 * 24 * time.Hour)})
+		if err := m.Refresh(context.Background(), 1); err != nil {
+			t.Fatalf("Refresh() err = %v", err)
+		}
+		if got := m.Warnings(1); len(got) != 1 {
+			t.Errorf("Warnings() = %v, want exactly one warning", got)
+		}
+	})
+
+	t.Run("warns when already expired", func(t *testing.T) {
+		m, _ := newTestManager(t, Entitlements{RealmID: 1, NotAfter: now.Add(-time.Hour)})
+		if err := m.Refresh(context.Background(), 1); err != nil {
+			t.Fatalf("Refresh() err = %v", err)
+		}
+		if got := m.Warnings(1); len(got) != 1 {
+			t.Errorf("Warnings() = %v, want exactly one warning", got)
+		}
+	})
+
+	t.Run("no warnings before any fetch", func(t *testing.T) {
+		m, _ := newTestManager(t, Entitlements{RealmID: 1, NotAfter: now.Add(-time.Hour)})
+		if got := m.Warnings(1); got != nil {
+			t.Errorf("Warnings() = %v, want nil for an unfetched realm", got)
+		}
+	})
+}
+
+func TestManagerPublicKey(t *testing.T) {
+	m, pub := newTestManager(t, Entitlements{RealmID: 1})
+	if string(m.PublicKey()) != string(pub) {
+		t.Error("PublicKey() did not return the key the Manager was constructed with")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entitlements
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/google/exposure-notifications-verification-server/pkg/database"
+)
+
+// Source fetches the latest signed entitlements blob for a realm. The
+// Manager never trusts a Source's result until Verify has checked it.
+type Source interface {
+	Fetch(ctx context.Context, realmID uint) (blob, sig []byte, err error)
+}
+
+// DBSource reads the entitlements blob the admin server last accepted via
+// HandleApplyEntitlements and persisted on the realm row.
+type DBSource struct {
+	DB *database.Database
+}
+
+func (s *DBSource) Fetch(ctx context.Context, realmID uint) ([]byte, []byte, error) {
+	realm, err := s.DB.FindRealm(realmID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load realm %d: %w", realmID, err)
+	}
+	return realm.EntitlementsBlob, realm.EntitlementsSignature, nil
+}
+
+// SignerURLSource fetches a fresh blob from an external signer service,
+// for deployments that mint entitlements outside this server entirely.
+type SignerURLSource struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func (s *SignerURLSource) Fetch(ctx context.Context, realmID uint) ([]byte, []byte, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/realms/%d/entitlements", s.BaseURL, realmID), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build signer request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to reach entitlements signer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("entitlements signer returned status %d", resp.StatusCode)
+	}
+
+	blob, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read signer response: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(resp.Header.Get("X-Entitlements-Signature"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode signer signature header: %w", err)
+	}
+	return blob, sig, nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entitlements
+
+// Watcher is notified whenever a realm's entitlements change, are about to
+// expire, or the Manager stops watching that realm. Implementations must
+// return quickly - they are invoked synchronously from the Manager's
+// refresh loop.
+type Watcher interface {
+	// OnNewEntitlements is called after a fetch produces an Entitlements
+	// value that differs from the previously cached one (including the
+	// very first successful fetch).
+	OnNewEntitlements(e Entitlements)
+
+	// OnExpiring is called once per refresh while the cached entitlements
+	// are within their expiring window, with the number of whole days
+	// left until NotAfter.
+	OnExpiring(daysLeft int)
+
+	// OnStopped is called when the unregister function returned by
+	// Manager.Watch is called. Manager.Stop does not call it.
+	OnStopped()
+}